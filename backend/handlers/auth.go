@@ -24,6 +24,47 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+const (
+	queryInsertUser         = "auth.insert_user"
+	queryClaimEmail         = "auth.claim_email"
+	queryReleaseEmailClaim  = "auth.release_email_claim"
+	queryInsertUserByEmail  = "auth.insert_user_by_email"
+	queryLoginByEmail       = "auth.login_by_email"
+	queryInsertRefreshToken = "auth.insert_refresh_token"
+	querySelectRefreshToken = "auth.select_refresh_token"
+	queryRevokeRefreshToken = "auth.revoke_refresh_token"
+)
+
+func init() {
+	db.Register(queryInsertUser, `INSERT INTO users (user_id,email,password_hash,name,role,created_at) VALUES (?,?,?,?,?,?)`)
+	db.Register(queryClaimEmail, `INSERT INTO users_by_email (email,user_id,password_hash,role) VALUES (?,?,?,?) IF NOT EXISTS`)
+	db.Register(queryReleaseEmailClaim, `DELETE FROM users_by_email WHERE email = ? IF user_id = ?`)
+	db.Register(queryInsertUserByEmail, `INSERT INTO users_by_email (email,user_id,password_hash,role) VALUES (?,?,?,?)`)
+	db.Register(queryLoginByEmail, `SELECT user_id, password_hash, role FROM users_by_email WHERE email = ?`)
+	db.Register(queryInsertRefreshToken, `INSERT INTO refresh_tokens (token,user_id,role,created_at,expires_at,revoked) VALUES (?,?,?,?,?,?)`)
+	db.Register(querySelectRefreshToken, `SELECT user_id, role, expires_at, revoked FROM refresh_tokens WHERE token = ?`)
+	db.Register(queryRevokeRefreshToken, `UPDATE refresh_tokens SET revoked = true WHERE token = ?`)
+}
+
+// issueRefreshToken generates a new opaque refresh token, stores it in
+// refresh_tokens, and returns it.
+func issueRefreshToken(userID gocql.UUID, role string) (string, error) {
+	refreshToken, err := utils.GenerateRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	if err := db.Exec(queryInsertRefreshToken,
+		refreshToken, userID, role, now, now.Add(utils.RefreshTokenTTL()), false); err != nil {
+		return "", err
+	}
+	return refreshToken, nil
+}
+
 func Signup(c *gin.Context) {
 	var req SignupRequest
 	if err := c.BindJSON(&req); err != nil {
@@ -38,8 +79,30 @@ func Signup(c *gin.Context) {
 	}
 
 	id := gocql.TimeUUID()
-	if err := db.Session.Query(`INSERT INTO users (user_id,email,password_hash,name,role,created_at) VALUES (?,?,?,?,?,?)`,
-		id, req.Email, string(hash), req.Name, req.Role, time.Now()).Exec(); err != nil {
+
+	// Claim the email first: IF NOT EXISTS makes this the uniqueness check,
+	// so a duplicate signup gets a 409 instead of a shadow account.
+	applied, err := db.Session.Query(db.MustLookup(queryClaimEmail), req.Email, id, string(hash), req.Role).MapScanCAS(map[string]interface{}{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error: " + err.Error()})
+		return
+	}
+	if !applied {
+		c.JSON(http.StatusConflict, gin.H{"error": "email already registered"})
+		return
+	}
+
+	// Write users and (again) users_by_email together. Scylla can't run a
+	// conditional batch across two different partitions, so this can't be
+	// one atomic CAS with the claim above; if it fails, release the claim
+	// instead of leaving a users_by_email row with no matching users row,
+	// which would otherwise wedge the email forever (and break WebAuthn
+	// registration for it, since that flow reads users by user_id).
+	batch := db.Session.NewBatch(gocql.LoggedBatch)
+	batch.Query(db.MustLookup(queryInsertUser), id, req.Email, string(hash), req.Name, req.Role, time.Now())
+	batch.Query(db.MustLookup(queryInsertUserByEmail), req.Email, id, string(hash), req.Role)
+	if err := db.Session.ExecuteBatch(batch); err != nil {
+		_, _ = db.Session.Query(db.MustLookup(queryReleaseEmailClaim), req.Email, id).MapScanCAS(map[string]interface{}{})
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error: " + err.Error()})
 		return
 	}
@@ -53,12 +116,12 @@ func Login(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
 		return
 	}
-	// fetch user
+	// fetch user via the email lookup table instead of ALLOW FILTERING on users
 	var userID gocql.UUID
 	var passhash string
 	var role string
-	q := `SELECT user_id, password_hash, role FROM users WHERE email = ? ALLOW FILTERING` // ALLOW FILTERING ok for small MVP
-	if err := db.Session.Query(q, req.Email).Consistency(gocql.One).Scan(&userID, &passhash, &role); err != nil {
+	if err := db.Session.Query(db.MustLookup(queryLoginByEmail), req.Email).
+		Consistency(gocql.LocalQuorum).Scan(&userID, &passhash, &role); err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 		return
 	}
@@ -71,5 +134,51 @@ func Login(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "token generation failed"})
 		return
 	}
-	c.JSON(200, gin.H{"token": token})
+	refreshToken, err := issueRefreshToken(userID, role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "refresh token generation failed"})
+		return
+	}
+	c.JSON(200, gin.H{"token": token, "refresh_token": refreshToken})
+}
+
+// RefreshToken rotates a refresh token: the presented token is revoked and
+// a new access/refresh token pair is issued, as long as it hasn't already
+// been revoked or expired.
+func RefreshToken(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	var userID gocql.UUID
+	var role string
+	var expiresAt time.Time
+	var revoked bool
+	if err := db.Scan(querySelectRefreshToken, []interface{}{req.RefreshToken}, &userID, &role, &expiresAt, &revoked); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+		return
+	}
+	if revoked || time.Now().After(expiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token revoked or expired"})
+		return
+	}
+
+	if err := db.Exec(queryRevokeRefreshToken, req.RefreshToken); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error: " + err.Error()})
+		return
+	}
+
+	token, err := utils.GenerateToken(userID.String(), role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "token generation failed"})
+		return
+	}
+	newRefreshToken, err := issueRefreshToken(userID, role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "refresh token generation failed"})
+		return
+	}
+	c.JSON(200, gin.H{"token": token, "refresh_token": newRefreshToken})
 }
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gocql/gocql"
+
+	"edu-mvp/db"
+	"edu-mvp/internal/oidc"
+	"edu-mvp/utils"
+)
+
+// OIDCProviders holds the configured SSO providers, set once at startup by
+// main.go from oidc.LoadProviders (env-driven).
+var OIDCProviders map[string]*oidc.Provider
+
+const (
+	queryOIDCLookupEmail = "oidc.lookup_user_by_email"
+	queryOIDCClaimEmail  = "oidc.claim_email"
+	queryOIDCInsertUser  = "oidc.insert_user"
+	queryOIDCInsertFlow  = "oidc.insert_flow"
+	queryOIDCSelectFlow  = "oidc.select_flow"
+	queryOIDCDeleteFlow  = "oidc.delete_flow"
+)
+
+func init() {
+	db.Register(queryOIDCLookupEmail, `SELECT user_id FROM users_by_email WHERE email = ?`)
+	db.Register(queryOIDCClaimEmail, `INSERT INTO users_by_email (email,user_id,password_hash,role) VALUES (?,?,?,?) IF NOT EXISTS`)
+	db.Register(queryOIDCInsertUser, `INSERT INTO users (user_id,email,password_hash,name,role,created_at) VALUES (?,?,?,?,?,?)`)
+	db.Register(queryOIDCInsertFlow, `INSERT INTO oidc_flows (state, code_verifier, nonce, redirect_after, created_at) VALUES (?,?,?,?,?)`)
+	db.Register(queryOIDCSelectFlow, `SELECT code_verifier, nonce, redirect_after FROM oidc_flows WHERE state = ?`)
+	db.Register(queryOIDCDeleteFlow, `DELETE FROM oidc_flows WHERE state = ?`)
+}
+
+// OIDCStart redirects the browser to the given provider's authorize
+// endpoint, storing the PKCE verifier and nonce for the callback to use.
+func OIDCStart(c *gin.Context) {
+	provider, ok := OIDCProviders[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown oidc provider"})
+		return
+	}
+
+	state, err := oidc.NewState()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start oidc flow"})
+		return
+	}
+	nonce, err := oidc.NewState()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start oidc flow"})
+		return
+	}
+	verifier, challenge, err := oidc.NewPKCEPair()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start oidc flow"})
+		return
+	}
+
+	authURL, err := provider.AuthURL(state, nonce, challenge)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "provider discovery failed: " + err.Error()})
+		return
+	}
+
+	if err := db.Exec(queryOIDCInsertFlow, state, verifier, nonce, c.Query("redirect_after"), time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error: " + err.Error()})
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback exchanges the authorization code, verifies the ID token, and
+// upserts/logs in the local user.
+func OIDCCallback(c *gin.Context) {
+	provider, ok := OIDCProviders[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown oidc provider"})
+		return
+	}
+
+	state := c.Query("state")
+	code := c.Query("code")
+	if state == "" || code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing state or code"})
+		return
+	}
+
+	var verifier, nonce, redirectAfter string
+	if err := db.Scan(queryOIDCSelectFlow, []interface{}{state}, &verifier, &nonce, &redirectAfter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown or expired oidc state"})
+		return
+	}
+	_ = db.Exec(queryOIDCDeleteFlow, state)
+
+	tokens, err := provider.ExchangeCode(code, verifier)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "code exchange failed: " + err.Error()})
+		return
+	}
+
+	claims, err := provider.VerifyIDToken(tokens.IDToken, nonce)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "id token verification failed: " + err.Error()})
+		return
+	}
+
+	email, _ := claims["email"].(string)
+	if email == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "id token missing email"})
+		return
+	}
+	role := provider.RoleForGroups(stringSlice(claims[provider.GroupClaim]))
+
+	userID, err := upsertSSOUser(email, role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error: " + err.Error()})
+		return
+	}
+
+	token, err := utils.GenerateToken(userID.String(), role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "token generation failed"})
+		return
+	}
+	refreshToken, err := issueRefreshToken(userID, role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "refresh token generation failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"token": token, "refresh_token": refreshToken, "redirect_after": redirectAfter})
+}
+
+// upsertSSOUser matches an existing user by email via users_by_email (the
+// same lookup table Signup and Login use), or claims the email and creates
+// one with no local password. Routing through users_by_email's IF NOT
+// EXISTS claim means an SSO signup can't create a second user_id for an
+// email that already has a password account, and vice versa.
+func upsertSSOUser(email, role string) (gocql.UUID, error) {
+	var userID gocql.UUID
+	if err := db.Scan(queryOIDCLookupEmail, []interface{}{email}, &userID); err == nil {
+		return userID, nil
+	}
+
+	userID = gocql.TimeUUID()
+	applied, err := db.Session.Query(db.MustLookup(queryOIDCClaimEmail), email, userID, "", role).MapScanCAS(map[string]interface{}{})
+	if err != nil {
+		return gocql.UUID{}, err
+	}
+	if !applied {
+		// Lost the race to a concurrent signup/SSO login for this email;
+		// use the user_id that won instead of creating a shadow account.
+		if err := db.Scan(queryOIDCLookupEmail, []interface{}{email}, &userID); err != nil {
+			return gocql.UUID{}, err
+		}
+		return userID, nil
+	}
+
+	if err := db.Exec(queryOIDCInsertUser, userID, email, "", email, role, time.Now()); err != nil {
+		return gocql.UUID{}, err
+	}
+	return userID, nil
+}
+
+// stringSlice normalizes a claim value that may be a single string or a
+// JSON array of strings (how "groups"-style claims are typically shaped).
+func stringSlice(v interface{}) []string {
+	switch t := v.(type) {
+	case []interface{}:
+		out := make([]string, 0, len(t))
+		for _, e := range t {
+			if s, ok := e.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	case string:
+		return []string{t}
+	default:
+		return nil
+	}
+}
@@ -1,36 +1,197 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
 	"net/http"
+	"os"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gocql/gocql"
-	"github.com/google/uuid"
 
 	"edu-mvp/db"
 )
 
-type UploadReq struct {
-	Filename string `json:"filename"`
-	CsvText  string `json:"csv_text"`
+const (
+	defaultMaxUploadBytes = 10 << 20 // 10MB
+	defaultUploadBatch    = 100
+)
+
+const (
+	queryUploadBySHA       = "upload.by_sha256"
+	queryUploadRowCount    = "upload.row_count"
+	queryInsertUploadRow   = "upload.insert_row"
+	queryInsertScoreByFac  = "upload.insert_score_by_faculty"
+	queryInsertScoreByMail = "upload.insert_score_by_email"
+	queryInsertFacUpload   = "upload.insert_faculty_upload"
+	queryInsertUploadBySHA = "upload.insert_by_sha256"
+)
+
+func init() {
+	db.Register(queryUploadBySHA, `SELECT upload_id FROM uploads_by_sha256 WHERE faculty_id = ? AND sha256 = ?`)
+	db.Register(queryUploadRowCount, `SELECT row_count FROM faculty_uploads WHERE upload_id = ?`)
+	db.Register(queryInsertUploadRow, `INSERT INTO upload_rows (upload_id, row_num, columns) VALUES (?,?,?)`)
+	db.Register(queryInsertScoreByFac, `INSERT INTO student_scores_by_faculty (faculty_id, course_id, student_email, score, upload_id, upload_ts) VALUES (?,?,?,?,?,?)`)
+	db.Register(queryInsertScoreByMail, `INSERT INTO student_scores_by_email (student_email, upload_ts, faculty_id, course_id, score) VALUES (?,?,?,?,?)`)
+	db.Register(queryInsertFacUpload, `INSERT INTO faculty_uploads (upload_id, faculty_id, filename, row_count, sha256, upload_ts) VALUES (?,?,?,?,?,?)`)
+	db.Register(queryInsertUploadBySHA, `INSERT INTO uploads_by_sha256 (faculty_id, sha256, upload_id) VALUES (?,?,?)`)
+}
+
+// RejectedRow describes a CSV row that could not be parsed or failed
+// validation. No score record is written for it; a row that parsed but
+// failed validation is still kept as raw columns in upload_rows.
+type RejectedRow struct {
+	RowNum int    `json:"row_num"`
+	Reason string `json:"reason"`
 }
 
-func UploadCSVStub(c *gin.Context) {
-	// token middleware must have set userID in context, for MVP we'll skip strict checks
-	var req UploadReq
-	if err := c.BindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+func maxUploadBytes() int64 {
+	if v := os.Getenv("UPLOAD_MAX_BYTES"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			return n
+		}
+	}
+	return defaultMaxUploadBytes
+}
+
+func uploadBatchSize() int {
+	if v := os.Getenv("UPLOAD_BATCH_SIZE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return defaultUploadBatch
+}
+
+// UploadCSV streams a multipart CSV upload into per-row Scylla records
+// instead of stuffing the whole file into a single text column.
+func UploadCSV(c *gin.Context) {
+	facultyID := c.MustGet("userID").(gocql.UUID)
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadBytes())
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing file"})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not open upload"})
+		return
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "upload too large or unreadable: " + err.Error()})
+		return
+	}
+	sum := hex.EncodeToString(hasher.Sum(nil))
+
+	// Idempotency: re-uploading the same bytes returns the original upload,
+	// scoped to this faculty so two faculty uploading identical files don't
+	// collide on each other's upload_id.
+	var existingID gocql.UUID
+	if err := db.Scan(queryUploadBySHA, []interface{}{facultyID, sum}, &existingID); err == nil {
+		var rowCount int
+		_ = db.Scan(queryUploadRowCount, []interface{}{existingID}, &rowCount)
+		c.JSON(http.StatusOK, gin.H{"upload_id": existingID.String(), "row_count": rowCount, "rejected_rows": []RejectedRow{}})
 		return
 	}
-	// In real flow, get faculty id from token
-	facultyID := gocql.TimeUUID()
 
-	uploadID := uuid.New()
-	if err := db.Session.Query(`INSERT INTO faculty_uploads (upload_id, faculty_id, filename, upload_ts, file_data) VALUES (?,?,?,?,?)`,
-		uploadID, facultyID, req.Filename, time.Now(), req.CsvText).Exec(); err != nil {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not rewind upload"})
+		return
+	}
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "empty or invalid csv"})
+		return
+	}
+
+	uploadID := gocql.TimeUUID()
+	batchSize := uploadBatchSize()
+	batch := db.Session.NewBatch(gocql.LoggedBatch)
+	rejected := []RejectedRow{}
+	rowNum := 0
+	rowCount := 0
+
+	flush := func() error {
+		if batch.Size() == 0 {
+			return nil
+		}
+		err := db.Session.ExecuteBatch(batch)
+		batch = db.Session.NewBatch(gocql.LoggedBatch)
+		return err
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		rowNum++
+		if err != nil {
+			rejected = append(rejected, RejectedRow{RowNum: rowNum, Reason: err.Error()})
+			continue
+		}
+
+		columns := make(map[string]string, len(header))
+		for i, col := range header {
+			if i < len(record) {
+				columns[col] = record[i]
+			}
+		}
+		batch.Query(db.MustLookup(queryInsertUploadRow), uploadID, rowNum, columns)
+
+		studentEmail := columns["student_email"]
+		courseID := columns["course_id"]
+		scoreText := columns["score"]
+		switch {
+		case studentEmail == "" || courseID == "":
+			rejected = append(rejected, RejectedRow{RowNum: rowNum, Reason: "missing student_email or course_id"})
+		default:
+			score, err := strconv.ParseFloat(scoreText, 64)
+			if err != nil {
+				rejected = append(rejected, RejectedRow{RowNum: rowNum, Reason: fmt.Sprintf("score %q is not numeric", scoreText)})
+				break
+			}
+			now := time.Now()
+			batch.Query(db.MustLookup(queryInsertScoreByFac), facultyID, courseID, studentEmail, score, uploadID, now)
+			batch.Query(db.MustLookup(queryInsertScoreByMail), studentEmail, now, facultyID, courseID, score)
+			rowCount++
+		}
+
+		if batch.Size() >= batchSize {
+			if err := flush(); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "db error: " + err.Error()})
+				return
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error: " + err.Error()})
+		return
+	}
+
+	if err := db.Exec(queryInsertFacUpload, uploadID, facultyID, fileHeader.Filename, rowCount, sum, time.Now()); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error: " + err.Error()})
 		return
 	}
-	c.JSON(200, gin.H{"upload_id": uploadID.String()})
+	if err := db.Exec(queryInsertUploadBySHA, facultyID, sum, uploadID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"upload_id":     uploadID.String(),
+		"row_count":     rowCount,
+		"rejected_rows": rejected,
+	})
 }
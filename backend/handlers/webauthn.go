@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/gocql/gocql"
+
+	"edu-mvp/db"
+	"edu-mvp/utils"
+)
+
+// WebAuthnInstance is the shared relying-party handle used to build and
+// verify registration/assertion ceremonies. It is set once at startup by
+// InitWebAuthn (see main.go), the same way db.Session is set by ConnectScylla.
+var WebAuthnInstance *webauthn.WebAuthn
+
+const (
+	queryWebAuthnUserByEmail   = "webauthn.user_by_email"
+	queryWebAuthnLoadCreds     = "webauthn.load_credentials"
+	queryWebAuthnEmailByUser   = "webauthn.email_by_user"
+	queryWebAuthnInsertCred    = "webauthn.insert_credential"
+	queryWebAuthnEmailRoleUser = "webauthn.email_role_by_user"
+	queryWebAuthnUpdateSignCnt = "webauthn.update_sign_count"
+)
+
+func init() {
+	db.Register(queryWebAuthnUserByEmail, `SELECT user_id FROM users_by_email WHERE email = ?`)
+	db.Register(queryWebAuthnLoadCreds, `SELECT credential_id, public_key, sign_count, aaguid, transports FROM webauthn_credentials WHERE user_id = ?`)
+	db.Register(queryWebAuthnEmailByUser, `SELECT email FROM users WHERE user_id = ?`)
+	db.Register(queryWebAuthnInsertCred, `INSERT INTO webauthn_credentials (user_id, credential_id, public_key, sign_count, aaguid, transports, created_at) VALUES (?,?,?,?,?,?,?)`)
+	db.Register(queryWebAuthnEmailRoleUser, `SELECT email, role FROM users WHERE user_id = ?`)
+	db.Register(queryWebAuthnUpdateSignCnt, `UPDATE webauthn_credentials SET sign_count = ? WHERE user_id = ? AND credential_id = ?`)
+}
+
+// InitWebAuthn configures the relying party from env vars:
+//   - WEBAUTHN_RP_ID:     the RP ID (e.g. "example.com")
+//   - WEBAUTHN_RP_ORIGIN: the expected origin (e.g. "https://example.com")
+//   - WEBAUTHN_RP_NAME:   the human-readable RP display name
+func InitWebAuthn() error {
+	rpID := os.Getenv("WEBAUTHN_RP_ID")
+	if rpID == "" {
+		rpID = "localhost"
+	}
+	rpOrigin := os.Getenv("WEBAUTHN_RP_ORIGIN")
+	if rpOrigin == "" {
+		rpOrigin = "http://localhost:5173"
+	}
+	rpName := os.Getenv("WEBAUTHN_RP_NAME")
+	if rpName == "" {
+		rpName = "Edu MVP"
+	}
+
+	w, err := webauthn.New(&webauthn.Config{
+		RPDisplayName: rpName,
+		RPID:          rpID,
+		RPOrigins:     []string{rpOrigin},
+	})
+	if err != nil {
+		return err
+	}
+	WebAuthnInstance = w
+	return nil
+}
+
+// webauthnUser adapts a Scylla-backed user and their stored credentials to
+// the webauthn.User interface expected by the library.
+type webauthnUser struct {
+	id          gocql.UUID
+	email       string
+	credentials []webauthn.Credential
+}
+
+func (u *webauthnUser) WebAuthnID() []byte                         { return []byte(u.id.String()) }
+func (u *webauthnUser) WebAuthnName() string                       { return u.email }
+func (u *webauthnUser) WebAuthnDisplayName() string                { return u.email }
+func (u *webauthnUser) WebAuthnCredentials() []webauthn.Credential { return u.credentials }
+
+// challengeSession holds the in-progress session data for a registration or
+// login ceremony. An entry is removed when it is looked up and refused once
+// older than challengeTTL; entries that are never looked up stay in the map.
+// A Scylla table isn't worth the round trip for data that lives a few minutes.
+type challengeSession struct {
+	data      webauthn.SessionData
+	userID    gocql.UUID
+	expiresAt time.Time
+}
+
+var (
+	challengeMu    sync.Mutex
+	challengeStore = map[string]*challengeSession{}
+)
+
+const challengeTTL = 5 * time.Minute
+
+func putChallenge(key string, userID gocql.UUID, data webauthn.SessionData) {
+	challengeMu.Lock()
+	defer challengeMu.Unlock()
+	challengeStore[key] = &challengeSession{data: data, userID: userID, expiresAt: time.Now().Add(challengeTTL)}
+}
+
+func takeChallenge(key string) (*challengeSession, bool) {
+	challengeMu.Lock()
+	defer challengeMu.Unlock()
+	cs, ok := challengeStore[key]
+	if ok {
+		delete(challengeStore, key)
+	}
+	if !ok || time.Now().After(cs.expiresAt) {
+		return nil, false
+	}
+	return cs, true
+}
+
+func loadCredentials(userID gocql.UUID) ([]webauthn.Credential, error) {
+	var creds []webauthn.Credential
+	iter := db.Session.Query(db.MustLookup(queryWebAuthnLoadCreds), userID).Iter()
+	var credID, pubKey, aaguid []byte
+	var signCount int
+	var transports []string
+	for iter.Scan(&credID, &pubKey, &signCount, &aaguid, &transports) {
+		ts := make([]protocol.AuthenticatorTransport, 0, len(transports))
+		for _, t := range transports {
+			ts = append(ts, protocol.AuthenticatorTransport(t))
+		}
+		creds = append(creds, webauthn.Credential{
+			ID:              credID,
+			PublicKey:       pubKey,
+			AttestationType: "none",
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    aaguid,
+				SignCount: uint32(signCount),
+			},
+			Transport: ts,
+		})
+	}
+	return creds, iter.Close()
+}
+
+// WebAuthnRegisterBegin starts a registration ceremony for the authenticated
+// user and returns the PublicKeyCredentialCreationOptions JSON.
+func WebAuthnRegisterBegin(c *gin.Context) {
+	userID, err := contextUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid session"})
+		return
+	}
+
+	var email string
+	if err := db.Scan(queryWebAuthnEmailByUser, []interface{}{userID}, &email); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	existing, err := loadCredentials(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error: " + err.Error()})
+		return
+	}
+	user := &webauthnUser{id: userID, email: email, credentials: existing}
+
+	options, session, err := WebAuthnInstance.BeginRegistration(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "begin registration failed: " + err.Error()})
+		return
+	}
+
+	putChallenge(session.Challenge, userID, *session)
+	c.JSON(http.StatusOK, options)
+}
+
+// WebAuthnRegisterFinish verifies the attestation returned by the browser
+// and stores the new credential.
+func WebAuthnRegisterFinish(c *gin.Context) {
+	userID, err := contextUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid session"})
+		return
+	}
+
+	challenge := c.Query("challenge")
+	cs, ok := takeChallenge(challenge)
+	if !ok || cs.userID != userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown or expired challenge"})
+		return
+	}
+
+	var email string
+	if err := db.Scan(queryWebAuthnEmailByUser, []interface{}{userID}, &email); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	user := &webauthnUser{id: userID, email: email}
+
+	cred, err := WebAuthnInstance.FinishRegistration(user, cs.data, c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "attestation verification failed: " + err.Error()})
+		return
+	}
+
+	transports := make([]string, 0, len(cred.Transport))
+	for _, t := range cred.Transport {
+		transports = append(transports, string(t))
+	}
+	if err := db.Exec(queryWebAuthnInsertCred,
+		userID, cred.ID, cred.PublicKey, int(cred.Authenticator.SignCount), cred.Authenticator.AAGUID, transports, time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "registered"})
+}
+
+type loginBeginRequest struct {
+	Email string `json:"email"`
+}
+
+// WebAuthnLoginBegin looks up the credentials registered to an email and
+// returns a fresh assertion challenge.
+func WebAuthnLoginBegin(c *gin.Context) {
+	var req loginBeginRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	var userID gocql.UUID
+	if err := db.Scan(queryWebAuthnUserByEmail, []interface{}{req.Email}, &userID); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no credentials for this email"})
+		return
+	}
+
+	creds, err := loadCredentials(userID)
+	if err != nil || len(creds) == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no credentials for this email"})
+		return
+	}
+	user := &webauthnUser{id: userID, email: req.Email, credentials: creds}
+
+	options, session, err := WebAuthnInstance.BeginLogin(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "begin login failed: " + err.Error()})
+		return
+	}
+
+	putChallenge(session.Challenge, userID, *session)
+	c.JSON(http.StatusOK, options)
+}
+
+// WebAuthnLoginFinish verifies the assertion signature, checks the sign
+// counter, and issues a JWT on success.
+func WebAuthnLoginFinish(c *gin.Context) {
+	challenge := c.Query("challenge")
+	cs, ok := takeChallenge(challenge)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown or expired challenge"})
+		return
+	}
+
+	var email, role string
+	if err := db.Scan(queryWebAuthnEmailRoleUser, []interface{}{cs.userID}, &email, &role); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+		return
+	}
+	creds, err := loadCredentials(cs.userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error: " + err.Error()})
+		return
+	}
+	user := &webauthnUser{id: cs.userID, email: email, credentials: creds}
+
+	cred, err := WebAuthnInstance.FinishLogin(user, cs.data, c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "assertion verification failed: " + err.Error()})
+		return
+	}
+	if cred.Authenticator.CloneWarning {
+		// FinishLogin doesn't error on this; it just flags that the sign
+		// count didn't strictly increase, which means two authenticators
+		// are sharing the same credential. Refuse rather than issue a token.
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authenticator clone detected"})
+		return
+	}
+
+	if err := db.Exec(queryWebAuthnUpdateSignCnt, int(cred.Authenticator.SignCount), cs.userID, cred.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error: " + err.Error()})
+		return
+	}
+
+	token, err := utils.GenerateToken(cs.userID.String(), role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "token generation failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"token": token})
+}
+
+// contextUserID reads the authenticated user id set by middleware.RequireAuth.
+func contextUserID(c *gin.Context) (gocql.UUID, error) {
+	raw, exists := c.Get("userID")
+	if !exists {
+		return gocql.UUID{}, gocql.ErrNotFound
+	}
+	if id, ok := raw.(gocql.UUID); ok {
+		return id, nil
+	}
+	return gocql.ParseUUID(strings.TrimSpace(raw.(string)))
+}
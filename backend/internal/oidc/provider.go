@@ -0,0 +1,251 @@
+// Package oidc implements the Authorization Code + PKCE flow against one or
+// more externally configured OIDC providers (institutional SSO).
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Provider holds a configured OIDC relying party's static config plus its
+// discovered endpoints and JWKS, fetched lazily on first use.
+type Provider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	RedirectURL  string
+
+	// GroupClaim is the ID token claim (e.g. "groups") inspected to derive
+	// the local role via GroupRoles. Defaults to "groups".
+	GroupClaim string
+	// GroupRoles maps an IdP group/claim value to a local role, e.g.
+	// {"faculty-staff": "faculty"}. Unmatched groups fall back to "student".
+	GroupRoles map[string]string
+
+	discoveryOnce sync.Once
+	discoveryErr  error
+	discovery     discoveryDocument
+	jwks          *jwksCache
+}
+
+type discoveryDocument struct {
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// LoadProviders reads the OIDC_PROVIDERS env var (a comma-separated list of
+// provider names, e.g. "google,keycloak") and builds a Provider for each
+// from its OIDC_<NAME>_* env vars.
+func LoadProviders() (map[string]*Provider, error) {
+	names := os.Getenv("OIDC_PROVIDERS")
+	if names == "" {
+		return map[string]*Provider{}, nil
+	}
+
+	providers := make(map[string]*Provider)
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+
+		p := &Provider{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			GroupClaim:   os.Getenv(prefix + "GROUP_CLAIM"),
+		}
+		if p.Issuer == "" || p.ClientID == "" {
+			return nil, fmt.Errorf("oidc provider %q missing issuer or client_id", name)
+		}
+		if scopes := os.Getenv(prefix + "SCOPES"); scopes != "" {
+			p.Scopes = strings.Split(scopes, ",")
+		} else {
+			p.Scopes = []string{"openid", "email", "profile"}
+		}
+		if p.GroupClaim == "" {
+			p.GroupClaim = "groups"
+		}
+		p.GroupRoles = parseRoleMap(os.Getenv(prefix + "ROLE_MAP"))
+
+		providers[name] = p
+	}
+	return providers, nil
+}
+
+// parseRoleMap parses "group1=role1,group2=role2" into a lookup map.
+func parseRoleMap(raw string) map[string]string {
+	out := map[string]string{}
+	for _, pair := range strings.Split(raw, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) == 2 {
+			out[kv[0]] = kv[1]
+		}
+	}
+	return out
+}
+
+// RoleForGroups returns the first local role mapped for any of the
+// presented group values, defaulting to "student".
+func (p *Provider) RoleForGroups(groups []string) string {
+	for _, g := range groups {
+		if role, ok := p.GroupRoles[g]; ok {
+			return role
+		}
+	}
+	return "student"
+}
+
+func (p *Provider) discover() error {
+	p.discoveryOnce.Do(func() {
+		resp, err := http.Get(strings.TrimSuffix(p.Issuer, "/") + "/.well-known/openid-configuration")
+		if err != nil {
+			p.discoveryErr = err
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			p.discoveryErr = fmt.Errorf("discovery returned %s", resp.Status)
+			return
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&p.discovery); err != nil {
+			p.discoveryErr = err
+			return
+		}
+		p.jwks = newJWKSCache(p.discovery.JWKSURI)
+	})
+	return p.discoveryErr
+}
+
+// AuthURL builds the authorize redirect URL for the Authorization Code +
+// PKCE flow.
+func (p *Provider) AuthURL(state, nonce, codeChallenge string) (string, error) {
+	if err := p.discover(); err != nil {
+		return "", err
+	}
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientID)
+	q.Set("redirect_uri", p.RedirectURL)
+	q.Set("scope", strings.Join(p.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", codeChallenge)
+	q.Set("code_challenge_method", "S256")
+	return p.discovery.AuthorizationEndpoint + "?" + q.Encode(), nil
+}
+
+// TokenResponse is the subset of the token endpoint's response this package
+// cares about.
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// ExchangeCode trades an authorization code and PKCE verifier for tokens.
+func (p *Provider) ExchangeCode(code, codeVerifier string) (*TokenResponse, error) {
+	if err := p.discover(); err != nil {
+		return nil, err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("client_id", p.ClientID)
+	form.Set("client_secret", p.ClientSecret)
+	form.Set("code_verifier", codeVerifier)
+
+	resp, err := http.PostForm(p.discovery.TokenEndpoint, form)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token exchange failed: %s: %s", resp.Status, body)
+	}
+
+	var tr TokenResponse
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return nil, err
+	}
+	return &tr, nil
+}
+
+// VerifyIDToken checks the ID token's signature against the provider's
+// cached JWKS and validates iss/aud/nonce.
+func (p *Provider) VerifyIDToken(idToken, expectedNonce string) (map[string]interface{}, error) {
+	if err := p.discover(); err != nil {
+		return nil, err
+	}
+	claims, err := p.jwks.verify(idToken)
+	if err != nil {
+		return nil, err
+	}
+	if iss, _ := claims["iss"].(string); iss != p.Issuer {
+		return nil, fmt.Errorf("unexpected issuer %q", iss)
+	}
+	if !audienceContains(claims["aud"], p.ClientID) {
+		return nil, fmt.Errorf("token not issued for this client")
+	}
+	if nonce, _ := claims["nonce"].(string); nonce != expectedNonce {
+		return nil, fmt.Errorf("nonce mismatch")
+	}
+	return claims, nil
+}
+
+func audienceContains(aud interface{}, clientID string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == clientID
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// NewPKCEPair returns a random code_verifier and its S256 code_challenge.
+func NewPKCEPair() (verifier, challenge string, err error) {
+	raw := make([]byte, 32)
+	if _, err = rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	verifier = base64.RawURLEncoding.EncodeToString(raw)
+	sum := sha256.Sum256([]byte(verifier))
+	challenge = base64.RawURLEncoding.EncodeToString(sum[:])
+	return verifier, challenge, nil
+}
+
+// NewState returns a random, URL-safe value suitable for both the state
+// and nonce parameters.
+func NewState() (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
@@ -9,6 +9,8 @@ import (
 
 	"edu-mvp/db"
 	"edu-mvp/handlers"
+	"edu-mvp/internal/oidc"
+	"edu-mvp/middleware"
 	"edu-mvp/utils"
 )
 
@@ -20,6 +22,18 @@ func main() {
 	}
 	utils.JwtSecret = []byte(jwtSecret)
 
+	// === Configure WebAuthn relying party ===
+	if err := handlers.InitWebAuthn(); err != nil {
+		log.Fatalf("❌ WebAuthn initialization failed: %v", err)
+	}
+
+	// === Configure OIDC SSO providers ===
+	oidcProviders, err := oidc.LoadProviders()
+	if err != nil {
+		log.Fatalf("❌ OIDC provider configuration failed: %v", err)
+	}
+	handlers.OIDCProviders = oidcProviders
+
 	// === Ensure Scylla schema ===
 	if err := db.EnsureSchema(); err != nil {
 		log.Fatalf("❌ Schema initialization failed: %v", err)
@@ -49,9 +63,23 @@ func main() {
 	// === Register API routes ===
 	api := r.Group("/")
 	{
+		// Public routes
 		api.POST("/signup", handlers.Signup)
 		api.POST("/login", handlers.Login)
-		api.POST("/upload-stub", handlers.UploadCSVStub)
+		api.POST("/refresh", handlers.RefreshToken)
+		api.POST("/webauthn/login/begin", handlers.WebAuthnLoginBegin)
+		api.POST("/webauthn/login/finish", handlers.WebAuthnLoginFinish)
+		api.GET("/auth/oidc/:provider/start", handlers.OIDCStart)
+		api.GET("/auth/oidc/:provider/callback", handlers.OIDCCallback)
+
+		// Authenticated routes
+		auth := api.Group("/")
+		auth.Use(middleware.RequireAuth())
+		{
+			auth.POST("/webauthn/register/begin", handlers.WebAuthnRegisterBegin)
+			auth.POST("/webauthn/register/finish", handlers.WebAuthnRegisterFinish)
+			auth.POST("/uploads", middleware.RequireRole("faculty"), handlers.UploadCSV)
+		}
 	}
 
 	// === Start server ===
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gocql/gocql"
+	"github.com/golang-jwt/jwt/v5"
+
+	"edu-mvp/utils"
+)
+
+// RequireAuth parses the Authorization: Bearer header, validates the JWT's
+// signature, exp/nbf/iss, and stores the authenticated userID and role in
+// the Gin context for downstream handlers.
+func RequireAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
+		if len(parts) != 2 || parts[0] != "Bearer" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing bearer token"})
+			return
+		}
+
+		token, err := jwt.Parse(parts[1], func(t *jwt.Token) (interface{}, error) {
+			return utils.JwtSecret, nil
+		}, jwt.WithValidMethods([]string{"HS256"}), jwt.WithIssuer(utils.TokenIssuer()), jwt.WithExpirationRequired())
+		if err != nil || !token.Valid {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
+			return
+		}
+
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token claims"})
+			return
+		}
+		sub, _ := claims["sub"].(string)
+		userID, err := gocql.ParseUUID(sub)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid subject"})
+			return
+		}
+		role, _ := claims["role"].(string)
+
+		c.Set("userID", userID)
+		c.Set("role", role)
+		c.Next()
+	}
+}
+
+// RequireRole aborts with 403 unless the authenticated user's role (set by
+// RequireAuth) is one of the allowed roles.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, _ := c.Get("role")
+		for _, r := range roles {
+			if role == r {
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient role"})
+	}
+}
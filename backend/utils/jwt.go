@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JwtSecret signs and verifies tokens issued by this service. It is set
+// once at startup in main.go from the JWT_SECRET env var.
+var JwtSecret []byte
+
+// TokenTTL is how long an access token is valid for, configurable via the
+// JWT_TTL env var (e.g. "15m", "1h"). Defaults to 1h.
+func TokenTTL() time.Duration {
+	if v := os.Getenv("JWT_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return time.Hour
+}
+
+// TokenIssuer is the "iss" claim embedded in and required of access tokens,
+// configurable via the JWT_ISSUER env var.
+func TokenIssuer() string {
+	if v := os.Getenv("JWT_ISSUER"); v != "" {
+		return v
+	}
+	return "edu-mvp"
+}
+
+// GenerateToken issues a signed JWT for a successfully authenticated user.
+func GenerateToken(userID string, role string) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub":  userID,
+		"role": role,
+		"iat":  now.Unix(),
+		"nbf":  now.Unix(),
+		"exp":  now.Add(TokenTTL()).Unix(),
+		"iss":  TokenIssuer(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(JwtSecret)
+}
+
+// RefreshTokenTTL is how long a refresh token is valid for, configurable
+// via the REFRESH_TTL env var. Defaults to 30 days.
+func RefreshTokenTTL() time.Duration {
+	if v := os.Getenv("REFRESH_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return 30 * 24 * time.Hour
+}
+
+// GenerateRefreshToken returns a random, URL-safe opaque token suitable for
+// storing in the refresh_tokens table and handing to the client.
+func GenerateRefreshToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
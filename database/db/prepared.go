@@ -0,0 +1,57 @@
+package db
+
+import (
+	"fmt"
+	"sync"
+)
+
+// prepared maps a short, handler-facing query name to its CQL string, so
+// handlers register their CQL once here and refer to it by name from then
+// on. It holds only the strings: Exec and Scan pass the registered string to
+// Session.Query, and preparing and caching the statement is left to gocql.
+var prepared sync.Map
+
+// Register associates name with a CQL query string. Call it once per query
+// during startup, before any handler uses Exec/Scan with that name.
+func Register(name, cql string) {
+	prepared.Store(name, cql)
+}
+
+func lookup(name string) (string, error) {
+	v, ok := prepared.Load(name)
+	if !ok {
+		return "", fmt.Errorf("db: query %q was never registered", name)
+	}
+	return v.(string), nil
+}
+
+// MustLookup returns the CQL registered under name, for call sites that
+// need the raw Session.Query (lightweight transactions, batches, custom
+// consistency) rather than the Exec/Scan convenience wrappers. It panics
+// if name was never registered, since that's a startup-time wiring bug.
+func MustLookup(name string) string {
+	cql, err := lookup(name)
+	if err != nil {
+		panic(err)
+	}
+	return cql
+}
+
+// Exec runs a registered, non-SELECT query by name.
+func Exec(name string, args ...interface{}) error {
+	cql, err := lookup(name)
+	if err != nil {
+		return err
+	}
+	return Session.Query(cql, args...).Exec()
+}
+
+// Scan runs a registered SELECT query by name and scans the single result
+// row into dest.
+func Scan(name string, args []interface{}, dest ...interface{}) error {
+	cql, err := lookup(name)
+	if err != nil {
+		return err
+	}
+	return Session.Query(cql, args...).Scan(dest...)
+}
@@ -75,19 +75,132 @@ func EnsureSchema() error {
 		return fmt.Errorf("failed to create users table: %v", err)
 	}
 
-	// Create faculty uploads table
+	// Lookup table so login can find a user by email without ALLOW FILTERING.
+	// Kept in sync with users on signup via a LOGGED BATCH.
+	usersByEmailCQL := `
+	CREATE TABLE IF NOT EXISTS users_by_email (
+		email text PRIMARY KEY,
+		user_id uuid,
+		password_hash text,
+		role text
+	);`
+	if err := ksSession.Query(usersByEmailCQL).Exec(); err != nil {
+		return fmt.Errorf("failed to create users_by_email table: %v", err)
+	}
+
+	// Create faculty uploads table (one row per ingested CSV file)
 	uploadsCQL := `
 	CREATE TABLE IF NOT EXISTS faculty_uploads (
 		upload_id uuid PRIMARY KEY,
 		faculty_id uuid,
 		filename text,
-		upload_ts timestamp,
-		file_data text
+		row_count int,
+		sha256 text,
+		upload_ts timestamp
 	);`
 	if err := ksSession.Query(uploadsCQL).Exec(); err != nil {
 		return fmt.Errorf("failed to create faculty_uploads table: %v", err)
 	}
 
+	// Lets a re-upload of the same bytes by the same faculty short-circuit to
+	// the original upload_id. Scoped by faculty_id so two different faculty
+	// members uploading byte-identical files don't collide on each other's upload.
+	uploadsBySHACQL := `
+	CREATE TABLE IF NOT EXISTS uploads_by_sha256 (
+		faculty_id uuid,
+		sha256 text,
+		upload_id uuid,
+		PRIMARY KEY ((faculty_id, sha256))
+	);`
+	if err := ksSession.Query(uploadsBySHACQL).Exec(); err != nil {
+		return fmt.Errorf("failed to create uploads_by_sha256 table: %v", err)
+	}
+
+	// Raw per-row data for an upload, keyed so a whole file's rows share a partition.
+	uploadRowsCQL := `
+	CREATE TABLE IF NOT EXISTS upload_rows (
+		upload_id uuid,
+		row_num int,
+		columns map<text, text>,
+		PRIMARY KEY ((upload_id), row_num)
+	);`
+	if err := ksSession.Query(uploadRowsCQL).Exec(); err != nil {
+		return fmt.Errorf("failed to create upload_rows table: %v", err)
+	}
+
+	// Query-optimized for a faculty listing scores for one of their courses.
+	scoresByFacultyCQL := `
+	CREATE TABLE IF NOT EXISTS student_scores_by_faculty (
+		faculty_id uuid,
+		course_id text,
+		student_email text,
+		score double,
+		upload_id uuid,
+		upload_ts timestamp,
+		PRIMARY KEY ((faculty_id, course_id), student_email)
+	);`
+	if err := ksSession.Query(scoresByFacultyCQL).Exec(); err != nil {
+		return fmt.Errorf("failed to create student_scores_by_faculty table: %v", err)
+	}
+
+	// Mirror so a student can list their own scores without ALLOW FILTERING.
+	scoresByEmailCQL := `
+	CREATE TABLE IF NOT EXISTS student_scores_by_email (
+		student_email text,
+		upload_ts timestamp,
+		faculty_id uuid,
+		course_id text,
+		score double,
+		PRIMARY KEY ((student_email), upload_ts, course_id)
+	);`
+	if err := ksSession.Query(scoresByEmailCQL).Exec(); err != nil {
+		return fmt.Errorf("failed to create student_scores_by_email table: %v", err)
+	}
+
+	// Create refresh tokens table (one row per active/revoked refresh token)
+	refreshTokensCQL := `
+	CREATE TABLE IF NOT EXISTS refresh_tokens (
+		token text PRIMARY KEY,
+		user_id uuid,
+		role text,
+		created_at timestamp,
+		expires_at timestamp,
+		revoked boolean
+	);`
+	if err := ksSession.Query(refreshTokensCQL).Exec(); err != nil {
+		return fmt.Errorf("failed to create refresh_tokens table: %v", err)
+	}
+
+	// Create OIDC flows table (short-lived state for the in-progress
+	// Authorization Code + PKCE exchange; rows expire on their own).
+	oidcFlowsCQL := `
+	CREATE TABLE IF NOT EXISTS oidc_flows (
+		state text PRIMARY KEY,
+		code_verifier text,
+		nonce text,
+		redirect_after text,
+		created_at timestamp
+	) WITH default_time_to_live = 600;`
+	if err := ksSession.Query(oidcFlowsCQL).Exec(); err != nil {
+		return fmt.Errorf("failed to create oidc_flows table: %v", err)
+	}
+
+	// Create webauthn credentials table (one row per registered authenticator)
+	webauthnCredsCQL := `
+	CREATE TABLE IF NOT EXISTS webauthn_credentials (
+		user_id uuid,
+		credential_id blob,
+		public_key blob,
+		sign_count int,
+		aaguid blob,
+		transports list<text>,
+		created_at timestamp,
+		PRIMARY KEY (user_id, credential_id)
+	);`
+	if err := ksSession.Query(webauthnCredsCQL).Exec(); err != nil {
+		return fmt.Errorf("failed to create webauthn_credentials table: %v", err)
+	}
+
 	fmt.Println("✅ Tables verified/created successfully in 'eduks'")
 	return nil
 }
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// WebAuthnCredential is a single registered authenticator for a user,
+// stored in ScyllaDB so a user can hold more than one passkey.
+type WebAuthnCredential struct {
+	UserID       gocql.UUID `json:"user_id"`
+	CredentialID []byte     `json:"credential_id"`
+	PublicKey    []byte     `json:"public_key"`
+	SignCount    uint32     `json:"sign_count"`
+	AAGUID       []byte     `json:"aaguid"`
+	Transports   []string   `json:"transports"`
+	CreatedAt    time.Time  `json:"created_at"`
+}